@@ -0,0 +1,228 @@
+// Package api exposes the running fetcher/player pipeline over HTTP so it
+// can be driven remotely (e.g. from Bitfocus Companion or a browser) instead
+// of only by ctrl-C'ing the process to change systems.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// QueuedCall is the wire representation of a pending call.
+type QueuedCall struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Time     string `json:"time"`
+	Priority int    `json:"priority"`
+}
+
+// SystemInfo is the wire representation of an OpenMHz system.
+type SystemInfo struct {
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+	City      string `json:"city"`
+	State     string `json:"state"`
+	Active    bool   `json:"active"`
+}
+
+// NowPlaying describes the call currently being streamed to the output
+// device, broadcast over the /nowplaying SSE endpoint.
+type NowPlaying struct {
+	Call      QueuedCall `json:"call"`
+	StartedAt time.Time  `json:"startedAt"`
+}
+
+// Metrics is a snapshot of pipeline health, served as plain JSON.
+type Metrics struct {
+	QueueDepth     int    `json:"queueDepth"`
+	ProcessedCalls int    `json:"processedCalls"`
+	CurrentSystem  string `json:"currentSystem"`
+	Paused         bool   `json:"paused"`
+}
+
+// Controller is the set of operations the API needs from the running
+// fetcher/player pipeline. main wires a concrete implementation in; the api
+// package itself stays decoupled from the fetcher/player internals.
+type Controller interface {
+	Systems() ([]SystemInfo, error)
+	SwitchSystem(shortName string) error
+	Queue() []QueuedCall
+	RemoveFromQueue(id string) bool
+	Skip()
+	Pause(paused bool)
+	Metrics() Metrics
+	Subscribe() (ch <-chan NowPlaying, cancel func())
+}
+
+// Server is the HTTP control plane. Start it alongside the existing
+// fetcher/player goroutines; it does not own their lifecycle.
+type Server struct {
+	logger     *logrus.Logger
+	controller Controller
+	addr       string
+}
+
+// NewServer builds a Server bound to addr (e.g. ":8080") that drives
+// controller.
+func NewServer(logger *logrus.Logger, controller Controller, addr string) *Server {
+	return &Server{logger: logger, controller: controller, addr: addr}
+}
+
+// ListenAndServe blocks serving the control API until the HTTP server
+// returns an error (including http.ErrServerClosed on graceful shutdown).
+func (s *Server) ListenAndServe() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/systems", s.handleSystems)
+	mux.HandleFunc("/system", s.handleSwitchSystem)
+	mux.HandleFunc("/queue", s.handleQueue)
+	mux.HandleFunc("/queue/", s.handleQueueItem)
+	mux.HandleFunc("/skip", s.handleSkip)
+	mux.HandleFunc("/pause", s.handlePause)
+	mux.HandleFunc("/nowplaying", s.handleNowPlaying)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	s.logger.Infof("API listening on %s", s.addr)
+	return http.ListenAndServe(s.addr, mux)
+}
+
+func (s *Server) handleSystems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	systems, err := s.controller.Systems()
+	if err != nil {
+		s.writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.writeJSON(w, systems)
+}
+
+func (s *Server) handleSwitchSystem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ShortName string `json:"shortName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.controller.SwitchSystem(req.ShortName); err != nil {
+		s.writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeJSON(w, s.controller.Queue())
+}
+
+func (s *Server) handleQueueItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/queue/")
+	if id == "" {
+		http.Error(w, "missing call id", http.StatusBadRequest)
+		return
+	}
+	if !s.controller.RemoveFromQueue(id) {
+		http.Error(w, "call not found in queue", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSkip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.controller.Skip()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Paused bool `json:"paused"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.controller.Pause(req.Paused)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleNowPlaying(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, cancel := s.controller.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		select {
+		case np, open := <-ch:
+			if !open {
+				return
+			}
+			payload, err := json.Marshal(np)
+			if err != nil {
+				s.logger.Warn("Failed to marshal now-playing event: ", err)
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeJSON(w, s.controller.Metrics())
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Warn("Failed to write JSON response: ", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
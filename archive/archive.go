@@ -0,0 +1,175 @@
+// Package archive persists downloaded calls instead of deleting them after
+// playback, indexing them in a SQLite database (no cgo) so they can be
+// listed, replayed, or served back over HTTP across restarts.
+package archive
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS calls (
+	id          TEXT PRIMARY KEY,
+	system      TEXT NOT NULL,
+	url         TEXT NOT NULL,
+	time        TEXT NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	talkgroup   TEXT NOT NULL DEFAULT '',
+	local_path  TEXT NOT NULL,
+	created_at  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_calls_system_time ON calls(system, time);
+`
+
+// Call is a single archived recording, as stored in the calls table.
+type Call struct {
+	ID         string
+	System     string
+	URL        string
+	Time       string
+	Duration   time.Duration
+	Talkgroup  string
+	LocalPath  string
+	ArchivedAt time.Time
+}
+
+// Store wraps the SQLite archive index.
+type Store struct {
+	db *sql.DB
+}
+
+// Open creates (if needed) and opens the SQLite database at dbPath,
+// including any parent directories.
+func Open(dbPath string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating archive directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening archive database: %w", err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error applying archive schema: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close releases the underlying database handle.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record inserts or replaces a call's archive metadata.
+func (s *Store) Record(call Call) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO calls (id, system, url, time, duration_ms, talkgroup, local_path, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		call.ID, call.System, call.URL, call.Time, call.Duration.Milliseconds(), call.Talkgroup, call.LocalPath,
+		call.ArchivedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("error recording call %s: %w", call.ID, err)
+	}
+	return nil
+}
+
+// SeedProcessed loads every known call ID into processed, so a restart
+// resumes dedupe from the archive instead of treating the next poll as the
+// first run and silently dropping it.
+func (s *Store) SeedProcessed(processed *sync.Map) error {
+	rows, err := s.db.Query(`SELECT id FROM calls`)
+	if err != nil {
+		return fmt.Errorf("error querying archived call ids: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("error scanning call id: %w", err)
+		}
+		processed.Store(id, true)
+	}
+	return rows.Err()
+}
+
+// Count returns the total number of archived calls, used to tell a
+// genuinely empty archive (nothing to seed dedupe state from) apart from
+// one that just hasn't recorded a particular call yet.
+func (s *Store) Count() (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM calls`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("error counting archived calls: %w", err)
+	}
+	return n, nil
+}
+
+// List returns archived calls for system (all systems if empty) recorded
+// at or after since.
+func (s *Store) List(system string, since time.Time) ([]Call, error) {
+	query := `SELECT id, system, url, time, duration_ms, talkgroup, local_path, created_at FROM calls WHERE created_at >= ?`
+	args := []interface{}{since.UTC().Format(time.RFC3339)}
+	if system != "" {
+		query += ` AND system = ?`
+		args = append(args, system)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []Call
+	for rows.Next() {
+		var (
+			c          Call
+			durationMS int64
+			createdAt  string
+		)
+		if err := rows.Scan(&c.ID, &c.System, &c.URL, &c.Time, &durationMS, &c.Talkgroup, &c.LocalPath, &createdAt); err != nil {
+			return nil, fmt.Errorf("error scanning call: %w", err)
+		}
+		c.Duration = time.Duration(durationMS) * time.Millisecond
+		c.ArchivedAt, _ = time.Parse(time.RFC3339, createdAt)
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// Get returns a single archived call by ID.
+func (s *Store) Get(id string) (Call, error) {
+	var (
+		c          Call
+		durationMS int64
+		createdAt  string
+	)
+	row := s.db.QueryRow(
+		`SELECT id, system, url, time, duration_ms, talkgroup, local_path, created_at FROM calls WHERE id = ?`, id,
+	)
+	if err := row.Scan(&c.ID, &c.System, &c.URL, &c.Time, &durationMS, &c.Talkgroup, &c.LocalPath, &createdAt); err != nil {
+		return Call{}, fmt.Errorf("error fetching call %s: %w", id, err)
+	}
+	c.Duration = time.Duration(durationMS) * time.Millisecond
+	c.ArchivedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return c, nil
+}
+
+// Path builds the on-disk location for an archived call's audio: baseDir/
+// system/YYYY-MM-DD/id.ext, matching the layout of a DVR-style recording
+// archive.
+func Path(baseDir, system string, when time.Time, id, ext string) string {
+	return filepath.Join(baseDir, system, when.Format("2006-01-02"), id+ext)
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alessiodam/OpenMHzPi/audio"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// defaultDBPath matches where the pipeline stores the archive by default;
+// flags below let it be overridden for a non-standard downloads directory.
+const defaultDBPath = "OpenMHzPi-downloads/archive.db"
+
+// NewCommand builds the `archive` cobra command and its list/play/serve
+// subcommands, to be mounted on the root command.
+func NewCommand(logger *logrus.Logger) *cobra.Command {
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Inspect and replay previously archived calls",
+	}
+	cmd.PersistentFlags().StringVar(&dbPath, "db", defaultDBPath, "Path to the archive SQLite database")
+
+	cmd.AddCommand(newListCommand(logger, &dbPath))
+	cmd.AddCommand(newPlayCommand(logger, &dbPath))
+	cmd.AddCommand(newServeCommand(logger, &dbPath))
+
+	return cmd
+}
+
+func newListCommand(logger *logrus.Logger, dbPath *string) *cobra.Command {
+	var system string
+	var since time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List archived calls",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := Open(*dbPath)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			calls, err := store.List(system, time.Now().Add(-since))
+			if err != nil {
+				return err
+			}
+
+			for _, c := range calls {
+				logger.Infof("%s  %-20s  %-10s  %s", c.ArchivedAt.Format(time.RFC3339), c.System, c.Duration, c.LocalPath)
+			}
+			logger.Infof("%d call(s)", len(calls))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&system, "system", "", "Filter by system shortName")
+	cmd.Flags().DurationVar(&since, "since", 24*time.Hour, "Only show calls archived within this window")
+	return cmd
+}
+
+func newPlayCommand(logger *logrus.Logger, dbPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "play <id>",
+		Short: "Replay a single archived call",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := Open(*dbPath)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			call, err := store.Get(args[0])
+			if err != nil {
+				return err
+			}
+
+			player, err := audio.NewPlayer(logger, 1)
+			if err != nil {
+				return fmt.Errorf("error initializing audio player: %w", err)
+			}
+
+			_, err = player.Play(context.Background(), audio.Source{ID: call.ID, Path: call.LocalPath, Gain: 1.0})
+			return err
+		},
+	}
+}
+
+func newServeCommand(logger *logrus.Logger, dbPath *string) *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an HTTP index and player for the archive",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := Open(*dbPath)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", serveIndex(store))
+			mux.HandleFunc("/play/", servePlayback(store))
+
+			logger.Infof("Archive server listening on %s", addr)
+			return http.ListenAndServe(addr, mux)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "listen", ":8090", "Address for the archive HTTP server to listen on")
+	return cmd
+}
+
+func serveIndex(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		system := r.URL.Query().Get("system")
+		calls, err := store.List(system, time.Now().Add(-7*24*time.Hour))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<!doctype html><html><body><ul>")
+		for _, c := range calls {
+			// System and ID come from the upstream OpenMHz API response, so
+			// they're escaped before being interpolated into markup.
+			fmt.Fprintf(w, `<li>%s — %s (%s) <a href="/play/%s">play</a></li>`,
+				c.ArchivedAt.Format(time.RFC3339), html.EscapeString(c.System), c.Duration, html.EscapeString(c.ID))
+		}
+		fmt.Fprint(w, "</ul></body></html>")
+	}
+}
+
+func servePlayback(store *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := filepath.Base(r.URL.Path)
+		call, err := store.Get(id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		f, err := os.Open(call.LocalPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", contentType(call.LocalPath))
+		http.ServeContent(w, r, filepath.Base(call.LocalPath), call.ArchivedAt, f)
+	}
+}
+
+// contentType maps an archived call's file extension to the MIME type
+// browsers need to play it back. Calls are archived as whatever decode()
+// originally consumed (typically m4a, the format OpenMHz serves), not a
+// fixed format, so this can't be hardcoded to audio/mpeg.
+func contentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg":
+		return "audio/ogg"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,464 @@
+// Package audio implements an in-process playback pipeline for downloaded
+// call recordings. mp3 and ogg/vorbis decode entirely in-process via
+// go-mp3/oggvorbis, streaming PCM to a single long-lived oto output device.
+//
+// m4a is the exception: it's the format OpenMHz actually serves call
+// recordings as, so it's the common case in practice, not an edge case, but
+// no pure-Go AAC decoder backs this package. m4a sources still shell out to
+// ffmpeg/ffprobe (see decodeM4A), which remains a hard runtime dependency on
+// a stock Pi install until a pure-Go AAC decoder replaces it.
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/hajimehoshi/oto/v2"
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sampleRate   = 44100
+	channelCount = 2
+	bitDepth     = 2 // 16-bit PCM
+)
+
+// Source describes a single decoded audio file queued for playback.
+type Source struct {
+	ID   string
+	Path string
+	// Gain is a linear multiplier applied to decoded samples before they
+	// reach the mixer. Callers must pass a resolved value (1.0 for no
+	// scaling); 0 is meaningful here (mutes the source), so there's no
+	// "unset" sentinel the way there is in talkgroup.Rule.Gain.
+	Gain float64
+}
+
+// decodedTrack is a fully decoded PCM stream ready to be fed to the mixer.
+type decodedTrack struct {
+	pcm      io.Reader
+	closer   io.Closer
+	duration time.Duration
+}
+
+// Player is a concurrent, in-process audio pipeline. It owns a single
+// oto.Context (the OS only allows one open output device at a time) and
+// mixes up to maxConcurrent overlapping calls into it, so simultaneous
+// transmissions overlap instead of queueing behind each other.
+type Player struct {
+	logger  *logrus.Logger
+	ctx     *oto.Context
+	ready   chan struct{}
+	sem     chan struct{}
+	mu      sync.Mutex
+	playing map[string]oto.Player
+
+	tapMu sync.Mutex
+	taps  map[chan []byte]struct{}
+}
+
+// NewPlayer opens the system audio device and returns a Player that allows
+// up to maxConcurrent calls to be mixed together. maxConcurrent <= 0 is
+// treated as 1 (no overlap).
+func NewPlayer(logger *logrus.Logger, maxConcurrent int) (*Player, error) {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	ctx, ready, err := oto.NewContext(sampleRate, channelCount, bitDepth)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio device: %w", err)
+	}
+
+	return &Player{
+		logger:  logger,
+		ctx:     ctx,
+		ready:   ready,
+		sem:     make(chan struct{}, maxConcurrent),
+		playing: make(map[string]oto.Player),
+		taps:    make(map[chan []byte]struct{}),
+	}, nil
+}
+
+// Tap subscribes to the raw PCM bytes as they are streamed to the output
+// device, so a consumer (e.g. the stream package's re-stream mount) can
+// re-encode the same audio without opening a second decode of the file.
+// Chunks are dropped for a subscriber that falls behind rather than
+// blocking playback.
+func (p *Player) Tap() (<-chan []byte, func()) {
+	ch := make(chan []byte, 64)
+
+	p.tapMu.Lock()
+	p.taps[ch] = struct{}{}
+	p.tapMu.Unlock()
+
+	cancel := func() {
+		p.tapMu.Lock()
+		delete(p.taps, ch)
+		p.tapMu.Unlock()
+		close(ch)
+	}
+	return ch, cancel
+}
+
+func (p *Player) broadcastPCM(chunk []byte) {
+	p.tapMu.Lock()
+	defer p.tapMu.Unlock()
+	if len(p.taps) == 0 {
+		return
+	}
+	for ch := range p.taps {
+		cp := make([]byte, len(chunk))
+		copy(cp, chunk)
+		select {
+		case ch <- cp:
+		default:
+			p.logger.Debug("Stream tap backpressured, dropping PCM chunk")
+		}
+	}
+}
+
+// Play decodes src.Path and streams it to the output device, blocking until
+// playback finishes, ctx is cancelled, or decoding fails. It returns the
+// track's duration: for mp3 and ogg as derived from the decoded sample
+// count, for m4a as reported by ffprobe.
+func (p *Player) Play(ctx context.Context, src Source) (time.Duration, error) {
+	<-p.ready
+
+	track, err := decode(src.Path)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding %s: %w", src.Path, err)
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	defer func() { <-p.sem }()
+
+	pcm := track.pcm
+	if src.Gain != 1.0 {
+		pcm = &gainReader{Reader: pcm, gain: src.Gain}
+	}
+
+	player := p.ctx.NewPlayer(&tappingReader{Reader: pcm, onRead: p.broadcastPCM})
+	p.mu.Lock()
+	p.playing[src.ID] = player
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.playing, src.ID)
+		p.mu.Unlock()
+		player.Close()
+		if track.closer != nil {
+			track.closer.Close()
+		}
+	}()
+
+	player.Play()
+
+	done := make(chan struct{})
+	go func() {
+		for player.IsPlaying() {
+			time.Sleep(50 * time.Millisecond)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return track.duration, nil
+	case <-ctx.Done():
+		return track.duration, ctx.Err()
+	}
+}
+
+// Stop interrupts the call identified by id if it is currently playing,
+// used by priority preemption to cut off a lower-priority transmission.
+func (p *Player) Stop(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	player, ok := p.playing[id]
+	if !ok {
+		return false
+	}
+	player.Close()
+	delete(p.playing, id)
+	return true
+}
+
+// decode dispatches on file extension to the appropriate decoder and returns
+// a PCM reader resampled and channel-matched to the player's fixed 44100Hz
+// stereo output format, along with the track's total duration. mp3 and ogg
+// are decoded fully into memory up front (call recordings are short) so
+// resampling can be done in one pass rather than streamed.
+func decode(path string) (*decodedTrack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return decodeMP3(f)
+	case ".ogg":
+		return decodeOggVorbis(f)
+	case ".m4a":
+		f.Close()
+		return decodeM4A(path)
+	default:
+		f.Close()
+		return nil, fmt.Errorf("unsupported audio format %q", filepath.Ext(path))
+	}
+}
+
+func decodeMP3(f *os.File) (*decodedTrack, error) {
+	dec, err := mp3.NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creating mp3 decoder: %w", err)
+	}
+	samples := dec.Length() / int64(channelCount*bitDepth)
+	duration := time.Duration(samples) * time.Second / time.Duration(dec.SampleRate())
+
+	// go-mp3 always decodes to stereo 16-bit PCM; only the sample rate
+	// needs matching to the player's fixed output rate.
+	pcm, err := io.ReadAll(dec)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding mp3: %w", err)
+	}
+	pcm = resamplePCM16(pcm, dec.SampleRate())
+
+	return &decodedTrack{pcm: bytes.NewReader(pcm), duration: duration}, nil
+}
+
+func decodeOggVorbis(f *os.File) (*decodedTrack, error) {
+	reader, err := oggvorbis.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creating ogg/vorbis decoder: %w", err)
+	}
+	duration := time.Duration(float64(reader.Length())/float64(reader.SampleRate())*1000) * time.Millisecond
+
+	pcm, err := io.ReadAll(&float32ToPCM16{source: reader})
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding ogg/vorbis: %w", err)
+	}
+	if reader.Channels() == 1 {
+		pcm = monoToStereo(pcm)
+	}
+	pcm = resamplePCM16(pcm, reader.SampleRate())
+
+	return &decodedTrack{pcm: bytes.NewReader(pcm), duration: duration}, nil
+}
+
+// decodeM4A shells out to ffmpeg/ffprobe for the one format the package's
+// pure-Go decoders can't handle: OpenMHz serves each call's original
+// recording as m4a (AAC), and no pure-Go AAC decoder exists to replace the
+// shell-outs this package otherwise removed. ffmpeg is asked to resample and
+// downmix directly to the player's output format, so no further conversion
+// is needed once it's streaming.
+func decodeM4A(path string) (*decodedTrack, error) {
+	duration, err := probeDuration(path)
+	if err != nil {
+		return nil, fmt.Errorf("error probing m4a duration: %w", err)
+	}
+
+	cmd := exec.Command("ffmpeg",
+		"-v", "quiet",
+		"-i", path,
+		"-f", "s16le",
+		"-ar", strconv.Itoa(sampleRate),
+		"-ac", strconv.Itoa(channelCount),
+		"-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating ffmpeg pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+
+	return &decodedTrack{pcm: stdout, closer: &cmdCloser{cmd: cmd}, duration: duration}, nil
+}
+
+// probeDuration asks ffprobe for a file's duration, used for the m4a decode
+// path since ffmpeg's raw PCM output carries no length we can read upfront.
+func probeDuration(path string) (time.Duration, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "quiet",
+		"-show_entries", "format=duration",
+		"-of", "csv=p=0",
+		path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("error running ffprobe: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing ffprobe duration %q: %w", out, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// cmdCloser waits for an ffmpeg subprocess to exit when the decoded track is
+// closed, so Play doesn't leak zombie processes.
+type cmdCloser struct {
+	cmd *exec.Cmd
+}
+
+func (c *cmdCloser) Close() error {
+	return c.cmd.Wait()
+}
+
+// resamplePCM16 linearly resamples interleaved little-endian 16-bit stereo
+// PCM from fromRate to the player's fixed sampleRate. Source files aren't
+// guaranteed to match the player's output rate — narrowband radio audio in
+// particular rarely does — so every in-process decoder routes through this
+// before the PCM reaches oto.
+func resamplePCM16(pcm []byte, fromRate int) []byte {
+	if fromRate == sampleRate || fromRate <= 0 || len(pcm) == 0 {
+		return pcm
+	}
+
+	const frameSize = channelCount * bitDepth
+	srcFrames := len(pcm) / frameSize
+	if srcFrames == 0 {
+		return pcm
+	}
+
+	readSample := func(frame, ch int) int16 {
+		if frame >= srcFrames {
+			frame = srcFrames - 1
+		}
+		off := frame*frameSize + ch*bitDepth
+		return int16(uint16(pcm[off]) | uint16(pcm[off+1])<<8)
+	}
+
+	dstFrames := int(int64(srcFrames) * int64(sampleRate) / int64(fromRate))
+	out := make([]byte, dstFrames*frameSize)
+	for i := 0; i < dstFrames; i++ {
+		srcPos := float64(i) * float64(fromRate) / float64(sampleRate)
+		frame0 := int(srcPos)
+		frac := srcPos - float64(frame0)
+
+		for ch := 0; ch < channelCount; ch++ {
+			s0 := float64(readSample(frame0, ch))
+			s1 := float64(readSample(frame0+1, ch))
+			v := int16(s0 + (s1-s0)*frac)
+			off := i*frameSize + ch*bitDepth
+			out[off] = byte(v)
+			out[off+1] = byte(v >> 8)
+		}
+	}
+	return out
+}
+
+// monoToStereo duplicates each little-endian 16-bit sample into both
+// channels, used for ogg/vorbis sources encoded in mono.
+func monoToStereo(pcm []byte) []byte {
+	out := make([]byte, len(pcm)*2)
+	for i, o := 0, 0; i+1 < len(pcm); i, o = i+2, o+4 {
+		out[o] = pcm[i]
+		out[o+1] = pcm[i+1]
+		out[o+2] = pcm[i]
+		out[o+3] = pcm[i+1]
+	}
+	return out
+}
+
+// float32ToPCM16 adapts a float32-sample decoder (as used by oggvorbis) to
+// the little-endian 16-bit PCM byte stream oto expects.
+type float32ToPCM16 struct {
+	source   interface{ Read([]float32) (int, error) }
+	buf      []float32
+	leftover []byte
+}
+
+func (a *float32ToPCM16) Read(p []byte) (int, error) {
+	if len(a.leftover) > 0 {
+		n := copy(p, a.leftover)
+		a.leftover = a.leftover[n:]
+		return n, nil
+	}
+
+	wantSamples := len(p) / 2
+	if wantSamples == 0 {
+		wantSamples = 1
+	}
+	if cap(a.buf) < wantSamples {
+		a.buf = make([]float32, wantSamples)
+	}
+	a.buf = a.buf[:wantSamples]
+
+	n, err := a.source.Read(a.buf)
+	if n == 0 {
+		return 0, err
+	}
+
+	out := make([]byte, 0, n*2)
+	for _, s := range a.buf[:n] {
+		v := int16(s * 32767)
+		out = append(out, byte(v), byte(v>>8))
+	}
+
+	written := copy(p, out)
+	if written < len(out) {
+		a.leftover = out[written:]
+	}
+	return written, err
+}
+
+// gainReader scales 16-bit little-endian PCM samples by a linear gain
+// factor, used to apply a talkgroup's configured volume during decode.
+type gainReader struct {
+	io.Reader
+	gain float64
+}
+
+func (g *gainReader) Read(p []byte) (int, error) {
+	n, err := g.Reader.Read(p)
+	for i := 0; i+1 < n; i += 2 {
+		sample := int16(uint16(p[i]) | uint16(p[i+1])<<8)
+		scaled := float64(sample) * g.gain
+		switch {
+		case scaled > 32767:
+			scaled = 32767
+		case scaled < -32768:
+			scaled = -32768
+		}
+		v := int16(scaled)
+		p[i] = byte(v)
+		p[i+1] = byte(v >> 8)
+	}
+	return n, err
+}
+
+// tappingReader forwards a copy of every chunk read to onRead, so live
+// listeners can be fed the exact PCM being sent to the output device.
+type tappingReader struct {
+	io.Reader
+	onRead func([]byte)
+}
+
+func (t *tappingReader) Read(p []byte) (int, error) {
+	n, err := t.Reader.Read(p)
+	if n > 0 {
+		t.onRead(p[:n])
+	}
+	return n, err
+}
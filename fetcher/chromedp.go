@@ -0,0 +1,166 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/sirupsen/logrus"
+)
+
+// cfClearanceFile is the cache file, relative to CacheDir, that persists
+// the Cloudflare clearance cookie between runs.
+const cfClearanceFile = "cf_clearance.json"
+
+// cachedCookie is the on-disk representation of a solved challenge cookie.
+type cachedCookie struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value"`
+	Domain  string    `json:"domain"`
+	Path    string    `json:"path"`
+	Expires time.Time `json:"expires"`
+}
+
+// chromedpFetcher solves the Cloudflare challenge itself with an embedded
+// headless browser the first time it's needed, then reuses the resulting
+// cf_clearance cookie with a plain HTTP client until it expires.
+type chromedpFetcher struct {
+	logger   *logrus.Logger
+	cacheDir string
+	direct   *directFetcher
+}
+
+func newChromedpFetcher(logger *logrus.Logger, cacheDir string) (*chromedpFetcher, error) {
+	if cacheDir == "" {
+		cacheDir = "."
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	f := &chromedpFetcher{
+		logger:   logger,
+		cacheDir: cacheDir,
+		direct:   newDirectFetcher(logger),
+	}
+
+	if cookie, ok := f.loadCachedCookie(); ok {
+		f.applyCookie(cookie)
+	}
+
+	return f, nil
+}
+
+func (f *chromedpFetcher) Get(ctx context.Context, target string) ([]byte, error) {
+	if body, err := f.direct.Get(ctx, target); err == nil {
+		return body, nil
+	}
+
+	f.logger.Info("Cached clearance missing or expired, solving Cloudflare challenge with chromedp")
+
+	cookie, body, err := f.solveChallenge(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("error solving cloudflare challenge: %w", err)
+	}
+
+	f.applyCookie(cookie)
+	if err := f.saveCachedCookie(cookie); err != nil {
+		f.logger.Warn("Failed to persist cf_clearance cookie: ", err)
+	}
+
+	return body, nil
+}
+
+func (f *chromedpFetcher) solveChallenge(ctx context.Context, target string) (cachedCookie, []byte, error) {
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, append(
+		chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+	)...)
+	defer cancelAlloc()
+
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	var bodyText string
+	var cookies []*network.Cookie
+	err := chromedp.Run(browserCtx,
+		chromedp.Navigate(target),
+		chromedp.Sleep(5*time.Second), // let the Cloudflare JS challenge resolve
+		chromedp.Text("body", &bodyText, chromedp.ByQuery),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			cookies, err = network.GetCookies().Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return cachedCookie{}, nil, fmt.Errorf("error running headless browser: %w", err)
+	}
+
+	for _, c := range cookies {
+		if c.Name == "cf_clearance" {
+			return cachedCookie{
+				Name:    c.Name,
+				Value:   c.Value,
+				Domain:  c.Domain,
+				Path:    c.Path,
+				Expires: time.Unix(int64(c.Expires), 0),
+			}, []byte(bodyText), nil
+		}
+	}
+
+	return cachedCookie{}, nil, fmt.Errorf("cf_clearance cookie not present after navigation")
+}
+
+func (f *chromedpFetcher) applyCookie(c cachedCookie) {
+	jar := f.direct.client.Jar
+	if jar == nil {
+		var err error
+		jar, err = cookiejar.New(nil)
+		if err != nil {
+			f.logger.Warn("Failed to create cookie jar: ", err)
+			return
+		}
+		f.direct.client.Jar = jar
+	}
+
+	u := &url.URL{Scheme: "https", Host: c.Domain}
+	jar.SetCookies(u, []*http.Cookie{{
+		Name:    c.Name,
+		Value:   c.Value,
+		Path:    c.Path,
+		Expires: c.Expires,
+	}})
+}
+
+func (f *chromedpFetcher) loadCachedCookie() (cachedCookie, bool) {
+	data, err := os.ReadFile(filepath.Join(f.cacheDir, cfClearanceFile))
+	if err != nil {
+		return cachedCookie{}, false
+	}
+
+	var c cachedCookie
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cachedCookie{}, false
+	}
+	if time.Now().After(c.Expires) {
+		return cachedCookie{}, false
+	}
+	return c, true
+}
+
+func (f *chromedpFetcher) saveCachedCookie(c cachedCookie) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error marshalling cookie: %w", err)
+	}
+	return os.WriteFile(filepath.Join(f.cacheDir, cfClearanceFile), data, 0o600)
+}
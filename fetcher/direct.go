@@ -0,0 +1,90 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/sirupsen/logrus"
+)
+
+// chromeUserAgent matches the TLS fingerprint requested below so the
+// HTTP-level User-Agent and the JA3 hash presented during the handshake
+// agree with each other.
+const chromeUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// directFetcher is a plain http.Client fronted by a uTLS round tripper that
+// mimics Chrome's TLS ClientHello (JA3), for polling systems where
+// Cloudflare isn't actively challenging the request.
+type directFetcher struct {
+	logger *logrus.Logger
+	client *http.Client
+}
+
+func newDirectFetcher(logger *logrus.Logger) *directFetcher {
+	jar, _ := cookiejar.New(nil)
+	return &directFetcher{
+		logger: logger,
+		client: &http.Client{
+			Jar: jar,
+			Transport: &http.Transport{
+				DialTLSContext: dialUTLS,
+			},
+		},
+	}
+}
+
+// dialUTLS opens a TLS connection using uTLS's Chrome ClientHello spec
+// instead of crypto/tls's default, matching Chrome's JA3 fingerprint.
+func dialUTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("error splitting host/port for %s: %w", addr, err)
+	}
+
+	dialer := &net.Dialer{}
+	rawConn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing %s: %w", addr, err)
+	}
+
+	uconn := utls.UClient(rawConn, &utls.Config{ServerName: host}, utls.HelloChrome_Auto)
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, fmt.Errorf("error completing uTLS handshake with %s: %w", addr, err)
+	}
+	return uconn, nil
+}
+
+func (d *directFetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", chromeUserAgent)
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error performing request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			d.logger.Warnf("Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,113 @@
+// Package fetcher abstracts how OpenMHz API requests get past Cloudflare.
+// fetchJSON used to hard-code a FlareSolverr proxy and parse its <pre>...
+// </pre> HTML wrapper, which broke the moment FlareSolverr changed its
+// response shape or wasn't running. Callers now depend on the Fetcher
+// interface and pick a concrete backend with --fetcher.
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fetcher retrieves the raw bytes of a URL, however that needs to happen
+// (a plain HTTP client, a FlareSolverr proxy, or a headless browser).
+type Fetcher interface {
+	Get(ctx context.Context, url string) ([]byte, error)
+}
+
+// Options configures the backend selected by New.
+type Options struct {
+	Logger *logrus.Logger
+
+	// ProxyURL is the FlareSolverr endpoint, used only by the
+	// "flaresolverr" backend.
+	ProxyURL string
+
+	// CacheDir is where the "chromedp" backend persists the
+	// cf_clearance cookie jar between runs.
+	CacheDir string
+
+	// MaxRetries and Backoff apply to every backend; backoff grows
+	// linearly with attempt number.
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// Backend names accepted by --fetcher.
+const (
+	Direct       = "direct"
+	FlareSolverr = "flaresolverr"
+	Chromedp     = "chromedp"
+)
+
+// New builds the Fetcher backend named by kind, wrapped with centralised
+// retry/backoff.
+func New(kind string, opts Options) (Fetcher, error) {
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 2 * time.Second
+	}
+
+	var f Fetcher
+	switch kind {
+	case Direct:
+		f = newDirectFetcher(opts.Logger)
+	case FlareSolverr:
+		if opts.ProxyURL == "" {
+			return nil, fmt.Errorf("flaresolverr fetcher requires a proxy URL")
+		}
+		f = newFlareSolverrFetcher(opts.Logger, opts.ProxyURL)
+	case Chromedp:
+		cf, err := newChromedpFetcher(opts.Logger, opts.CacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing chromedp fetcher: %w", err)
+		}
+		f = cf
+	default:
+		return nil, fmt.Errorf("unknown fetcher backend %q", kind)
+	}
+
+	return &retryingFetcher{
+		logger:     opts.Logger,
+		inner:      f,
+		maxRetries: opts.MaxRetries,
+		backoff:    opts.Backoff,
+	}, nil
+}
+
+// retryingFetcher centralises retry/backoff so individual backends don't
+// each reimplement it.
+type retryingFetcher struct {
+	logger     *logrus.Logger
+	inner      Fetcher
+	maxRetries int
+	backoff    time.Duration
+}
+
+func (r *retryingFetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= r.maxRetries; attempt++ {
+		body, err := r.inner.Get(ctx, url)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		r.logger.Warnf("Fetch attempt %d/%d failed for %s: %v", attempt+1, r.maxRetries+1, url, err)
+
+		if attempt == r.maxRetries {
+			break
+		}
+		select {
+		case <-time.After(r.backoff * time.Duration(attempt+1)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("all %d fetch attempts failed: %w", r.maxRetries+1, lastErr)
+}
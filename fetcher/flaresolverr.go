@@ -0,0 +1,100 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// flareSolverrFetcher proxies requests through a running FlareSolverr
+// instance, which drives a real browser to solve the Cloudflare challenge
+// and returns the page body wrapped in <pre>...</pre> HTML.
+type flareSolverrFetcher struct {
+	logger   *logrus.Logger
+	proxyURL string
+	client   *http.Client
+}
+
+func newFlareSolverrFetcher(logger *logrus.Logger, proxyURL string) *flareSolverrFetcher {
+	return &flareSolverrFetcher{logger: logger, proxyURL: proxyURL, client: &http.Client{}}
+}
+
+func (f *flareSolverrFetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	requestData := map[string]interface{}{
+		"cmd":        "request.get",
+		"url":        url,
+		"maxTimeout": 60000,
+	}
+
+	jsonData, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.proxyURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error performing request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			f.logger.Warnf("Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	return unwrapFlareSolverrResponse(f.logger, body)
+}
+
+func unwrapFlareSolverrResponse(logger *logrus.Logger, body []byte) ([]byte, error) {
+	htmlContent := string(body)
+	startIndex := strings.Index(htmlContent, "<pre>")
+	endIndex := strings.Index(htmlContent, "</pre>")
+
+	if startIndex == -1 || endIndex == -1 {
+		return nil, fmt.Errorf("failed to locate <pre> tags in response")
+	}
+
+	jsonStr := htmlContent[startIndex+len("<pre>") : endIndex]
+
+	unescapedJSON, err := strconv.Unquote(`"` + jsonStr + `"`)
+	if err != nil {
+		logger.Errorf("Raw JSON: %s", jsonStr)
+		return nil, fmt.Errorf("error unescaping JSON: %w", err)
+	}
+
+	return []byte(unescapedJSON), nil
+}
+
+// IsRunning reports whether a FlareSolverr instance is reachable at
+// proxyBaseURL (e.g. "http://localhost:8191"), i.e. whether its root
+// answers with 200 OK.
+func IsRunning(proxyBaseURL string) bool {
+	resp, err := http.Get(proxyBaseURL + "/")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -1,29 +1,39 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/alessiodam/OpenMHzPi/api"
+	"github.com/alessiodam/OpenMHzPi/archive"
+	"github.com/alessiodam/OpenMHzPi/audio"
+	"github.com/alessiodam/OpenMHzPi/fetcher"
+	"github.com/alessiodam/OpenMHzPi/stream"
+	"github.com/alessiodam/OpenMHzPi/talkgroup"
 	"github.com/cheggaaa/pb/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"strconv"
-	"strings"
-	"sync"
 	"syscall"
 	"time"
 )
 
+// MaxConcurrentCalls bounds how many overlapping transmissions the
+// AudioPlayer will mix together before new calls start blocking.
+const MaxConcurrentCalls = 4
+
 const (
 	MaxQueueSize    = 50
 	FetchInterval   = 5 * time.Second
 	AudioFolderPath = "OpenMHzPi-downloads"
+	// TempDownloadPath holds in-flight downloads before they're archived;
+	// only this subdirectory is wiped on startup, so archived calls from
+	// previous runs survive.
+	TempDownloadPath = AudioFolderPath + "/tmp"
 )
 
 type System struct {
@@ -43,11 +53,33 @@ type SystemsResponse struct {
 	Systems []System `json:"systems"`
 }
 
+// CallSource is one entry in a call's srcList: a radio unit that transmitted
+// during the call.
+type CallSource struct {
+	Src int    `json:"src"`
+	Tag string `json:"tag"`
+}
+
 type Call struct {
-	ID       string `json:"_id"`
-	URL      string `json:"url"`
-	Filename string `json:"filename"`
-	Time     string `json:"time"`
+	ID           string       `json:"_id"`
+	URL          string       `json:"url"`
+	Filename     string       `json:"filename"`
+	Time         string       `json:"time"`
+	Talkgroup    string       `json:"talkgroup"`
+	TalkgroupNum int          `json:"talkgroupNum"`
+	TalkgroupTag string       `json:"talkgroupTag"`
+	SrcList      []CallSource `json:"srcList"`
+	Len          float64      `json:"len"`
+}
+
+// UnitIDs returns the source unit IDs that transmitted during the call, for
+// matching against talkgroup rules keyed by unit ID.
+func (c Call) UnitIDs() []int {
+	units := make([]int, len(c.SrcList))
+	for i, s := range c.SrcList {
+		units[i] = s.Src
+	}
+	return units
 }
 
 type CallsResponse struct {
@@ -68,70 +100,8 @@ func initLogger(debug bool) *logrus.Logger {
 	return logger
 }
 
-func fetchJSON(logger *logrus.Logger, proxyURL, targetURL string) ([]byte, error) {
-	logger.Debugf("Fetching JSON via proxy. Target URL: %s", targetURL)
-
-	client := &http.Client{}
-
-	requestData := map[string]interface{}{
-		"cmd":        "request.get",
-		"url":        targetURL,
-		"maxTimeout": 60000,
-	}
-
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling request JSON: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", proxyURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error performing request: %w", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			logger.Warnf("Failed to close response body: %v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
-	}
-
-	htmlContent := string(body)
-	startIndex := strings.Index(htmlContent, "<pre>")
-	endIndex := strings.Index(htmlContent, "</pre>")
-
-	if startIndex == -1 || endIndex == -1 {
-		return nil, fmt.Errorf("failed to locate <pre> tags in response")
-	}
-
-	jsonStr := htmlContent[startIndex+len("<pre>") : endIndex]
-
-	unescapedJSON, err := strconv.Unquote(`"` + jsonStr + `"`)
-	if err != nil {
-		logger.Errorf("Error unescaping JSON: %v", err)
-		logger.Errorf("Raw JSON: %s", jsonStr)
-		return nil, fmt.Errorf("error unescaping JSON: %w", err)
-	}
-
-	return []byte(unescapedJSON), nil
-}
-
-func fetchSystems(logger *logrus.Logger, proxyURL string) (string, error) {
-	body, err := fetchJSON(logger, proxyURL, "https://api.openmhz.com/systems")
+func fetchSystems(logger *logrus.Logger, f fetcher.Fetcher) (string, error) {
+	body, err := f.Get(context.Background(), "https://api.openmhz.com/systems")
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch systems: %w", err)
 	}
@@ -159,126 +129,6 @@ func fetchSystems(logger *logrus.Logger, proxyURL string) (string, error) {
 	return shortName, nil
 }
 
-func fetchCalls(logger *logrus.Logger, proxyURL, systemShortName string, queue chan Call, processedCalls *sync.Map, done <-chan struct{}) {
-	apiURL := fmt.Sprintf("https://api.openmhz.com/%s/calls", systemShortName)
-	logger.Debugf("API URL: %s", apiURL)
-
-	isFirstRun := true
-
-	for {
-		select {
-		case <-done:
-			logger.Info("Stopping call fetcher.")
-			return
-		case <-time.After(FetchInterval):
-			logger.Debug("Fetching calls...")
-			body, err := fetchJSON(logger, proxyURL, apiURL)
-			if err != nil {
-				logger.Error("Error fetching calls: ", err)
-				continue
-			}
-
-			logger.Debugf("Fetched calls JSON: %s", string(body))
-			var callsResponse CallsResponse
-			if err := json.Unmarshal(body, &callsResponse); err != nil {
-				logger.Error("Error parsing calls JSON: ", err)
-				continue
-			}
-
-			logger.Debugf("Parsed %d calls", len(callsResponse.Calls))
-
-			for _, call := range callsResponse.Calls {
-				logger.Debugf("Processing call ID: %s", call.ID)
-
-				if isFirstRun {
-					processedCalls.Store(call.ID, true)
-					logger.Infof("Marked call ID %s as processed (initial run)", call.ID)
-					continue
-				}
-
-				if _, exists := processedCalls.LoadOrStore(call.ID, true); !exists {
-					select {
-					case queue <- call:
-						logger.Infof("New call added to queue: %s", call.ID)
-					default:
-						logger.Warn("Queue full, dropping oldest call.")
-						<-queue
-						queue <- call
-					}
-				} else {
-					logger.Debugf("Call ID %s already processed", call.ID)
-				}
-			}
-
-			if isFirstRun {
-				isFirstRun = false
-			}
-		}
-	}
-}
-
-func convertToMP3(inputPath, outputPath string) error {
-	cmd := exec.Command("ffmpeg", "-i", inputPath, outputPath)
-	return cmd.Run()
-}
-
-func getTrackLength(filePath string) (float64, error) {
-	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", filePath)
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, fmt.Errorf("error getting track length: %w", err)
-	}
-	lengthStr := strings.TrimSpace(string(output))
-	length, err := strconv.ParseFloat(lengthStr, 64)
-	if err != nil {
-		return 0, fmt.Errorf("error parsing track length: %w", err)
-	}
-	return length, nil
-}
-
-func playAudio(logger *logrus.Logger, queue <-chan Call, done <-chan struct{}) {
-	for {
-		select {
-		case <-done:
-			logger.Info("Stopping audio player.")
-			return
-		case call := <-queue:
-			logger.Infof("Processing call: %s", call.Filename)
-
-			filePath := fmt.Sprintf("%s/%s", AudioFolderPath, filepath.Base(call.Filename))
-			if err := downloadFile(call.URL, filePath); err != nil {
-				logger.Error("Failed to download file: ", err)
-				continue
-			}
-
-			mp3FilePath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".mp3"
-			if err := convertToMP3(filePath, mp3FilePath); err != nil {
-				logger.Error("Failed to convert file to MP3: ", err)
-				continue
-			}
-
-			trackLength, err := getTrackLength(mp3FilePath)
-			if err != nil {
-				logger.Error("Failed to get track length: ", err)
-				continue
-			}
-			logger.Infof("Track length: %.2f seconds", trackLength)
-
-			if err := playFile(mp3FilePath); err != nil {
-				logger.Error("Failed to play file: ", err)
-				continue
-			}
-
-			if err := os.Remove(filePath); err != nil {
-				logger.Warn("Failed to delete original file: ", err)
-			}
-			if err := os.Remove(mp3FilePath); err != nil {
-				logger.Warn("Failed to delete MP3 file: ", err)
-			}
-		}
-	}
-}
-
 func downloadFile(url, filepath string) error {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -303,69 +153,109 @@ func downloadFile(url, filepath string) error {
 	return nil
 }
 
-func playFile(filepath string) error {
-	cmd := exec.Command("mpg123", filepath)
-	return cmd.Run()
-}
-
-func isFlareSolverrRunning() bool {
-	resp, err := http.Get("http://localhost:8191/")
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
-}
-
 func main() {
 	var shortName string
 	var debug bool
+	var listenAddr string
+	var mountAddr string
+	var fetcherKind string
+	var flareSolverrURL string
+	var rulesPath string
 
 	rootCmd := &cobra.Command{
 		Use: "app",
 		Run: func(cmd *cobra.Command, args []string) {
 			logger := initLogger(debug)
 
-			if err := os.RemoveAll(AudioFolderPath); err != nil {
-				logger.Fatal("Failed to remove existing audio directory: ", err)
+			if err := os.RemoveAll(TempDownloadPath); err != nil {
+				logger.Fatal("Failed to remove existing temp download directory: ", err)
 			}
-			if err := os.MkdirAll(AudioFolderPath, os.ModePerm); err != nil {
-				logger.Fatal("Failed to create audio directory: ", err)
+			if err := os.MkdirAll(TempDownloadPath, os.ModePerm); err != nil {
+				logger.Fatal("Failed to create temp download directory: ", err)
 			}
 
-			proxyURL := "http://localhost:8191/v1"
-
-			if !isFlareSolverrRunning() {
-				logger.Fatal("FlareSolverr is not running. Please start it before running this application.")
+			f, err := fetcher.New(fetcherKind, fetcher.Options{
+				Logger:   logger,
+				ProxyURL: flareSolverrURL,
+				CacheDir: AudioFolderPath,
+			})
+			if err != nil {
+				logger.Fatal("Failed to initialize fetcher: ", err)
 			}
 
 			if shortName == "" {
-				var err error
-				shortName, err = fetchSystems(logger, proxyURL)
+				shortName, err = fetchSystems(logger, f)
 				if err != nil {
 					logger.Fatal(err)
 				}
 			}
 
-			queue := make(chan Call, MaxQueueSize)
-			processedCalls := &sync.Map{}
-			done := make(chan struct{})
+			player, err := audio.NewPlayer(logger, MaxConcurrentCalls)
+			if err != nil {
+				logger.Fatal("Failed to initialize audio player: ", err)
+			}
 
-			go fetchCalls(logger, proxyURL, shortName, queue, processedCalls, done)
-			go playAudio(logger, queue, done)
+			store, err := archive.Open(filepath.Join(AudioFolderPath, "archive.db"))
+			if err != nil {
+				logger.Fatal("Failed to open call archive: ", err)
+			}
+			defer store.Close()
+
+			mount := stream.NewMount(logger, player)
+
+			rules, err := talkgroup.NewWatcher(logger, rulesPath)
+			if err != nil {
+				logger.Fatal("Failed to load talkgroup rules: ", err)
+			}
+			defer rules.Close()
+
+			p := newPipeline(logger, f, shortName, player, mount, store, rules)
+			p.Run()
+
+			apiServer := api.NewServer(logger, p, listenAddr)
+			go func() {
+				if err := apiServer.ListenAndServe(); err != nil {
+					logger.Error("API server stopped: ", err)
+				}
+			}()
+
+			streamServer := stream.NewServer(logger, mountAddr, mount)
+			go func() {
+				if err := streamServer.ListenAndServe(); err != nil {
+					logger.Error("Re-stream mount stopped: ", err)
+				}
+			}()
 
 			c := make(chan os.Signal, 1)
 			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 			<-c
 
 			logger.Info("Shutting down...")
-			close(done)
+			p.Stop()
 			time.Sleep(2 * time.Second)
 		},
 	}
 
 	rootCmd.PersistentFlags().StringVar(&shortName, "shortname", "", "Short name of the system")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug mode")
+	rootCmd.PersistentFlags().StringVar(&listenAddr, "listen", ":8080", "Address for the HTTP control API to listen on")
+	rootCmd.PersistentFlags().StringVar(&mountAddr, "mount", ":8000", "Address for the Icecast-style re-stream mount to listen on")
+	rootCmd.PersistentFlags().StringVar(&fetcherKind, "fetcher", fetcher.Direct, "Cloudflare bypass backend: direct, flaresolverr, or chromedp")
+	rootCmd.PersistentFlags().StringVar(&flareSolverrURL, "flaresolverr-url", "http://localhost:8191/v1", "FlareSolverr endpoint, used by --fetcher=flaresolverr")
+	rootCmd.PersistentFlags().StringVar(&rulesPath, "rules", "", "Path to a YAML talkgroup rules file (allow/deny, priority, gain); hot-reloaded on change")
+
+	// initLogger(debug) can't be called here: debug is still its zero value
+	// until cobra parses flags during Execute. Build the archive command's
+	// logger once and bump its level in PersistentPreRun, which runs after
+	// parsing, so `--debug archive ...` is honored.
+	archiveLogger := initLogger(false)
+	archiveCmd := archive.NewCommand(archiveLogger)
+	archiveCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if debug {
+			archiveLogger.SetLevel(logrus.DebugLevel)
+		}
+	}
+	rootCmd.AddCommand(archiveCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println("Error:", err)
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alessiodam/OpenMHzPi/api"
+	"github.com/alessiodam/OpenMHzPi/archive"
+	"github.com/alessiodam/OpenMHzPi/audio"
+	"github.com/alessiodam/OpenMHzPi/fetcher"
+	"github.com/alessiodam/OpenMHzPi/stream"
+	"github.com/alessiodam/OpenMHzPi/talkgroup"
+	"github.com/sirupsen/logrus"
+)
+
+// pipeline owns the fetcher/player goroutines and is the Controller the api
+// package drives. It exists so the REST control plane can inspect and
+// mutate state (switch systems, skip, pause, list the queue) that used to
+// only be reachable by restarting the process.
+type pipeline struct {
+	logger         *logrus.Logger
+	fetcher        fetcher.Fetcher
+	player         *audio.Player
+	mount          *stream.Mount
+	archive        *archive.Store
+	rules          *talkgroup.Watcher
+	queue          *callQueue
+	processedCalls atomic.Pointer[sync.Map]
+	done           chan struct{}
+
+	mu        sync.Mutex
+	shortName string
+
+	// baselineOnly is set when the pipeline starts with no archived history
+	// to dedupe against (no archive configured, or a genuinely empty one).
+	// The first poll then only records what's currently live as the
+	// baseline instead of queuing it, so a fresh install doesn't play the
+	// entire backlog of calls already on the feed.
+	baselineOnly atomic.Bool
+
+	paused          atomic.Bool
+	skip            chan struct{}
+	isPlaying       atomic.Bool
+	currentPriority atomic.Int32
+
+	subsMu sync.Mutex
+	subs   map[chan api.NowPlaying]struct{}
+}
+
+func newPipeline(logger *logrus.Logger, f fetcher.Fetcher, shortName string, player *audio.Player, mount *stream.Mount, store *archive.Store, rules *talkgroup.Watcher) *pipeline {
+	processedCalls := &sync.Map{}
+	baselineOnly := true
+	if store != nil {
+		if err := store.SeedProcessed(processedCalls); err != nil {
+			logger.Warn("Failed to seed dedupe state from archive: ", err)
+		}
+		count, err := store.Count()
+		if err != nil {
+			logger.Warn("Failed to check archive size: ", err)
+		}
+		baselineOnly = count == 0
+	}
+
+	p := &pipeline{
+		logger:    logger,
+		fetcher:   f,
+		player:    player,
+		mount:     mount,
+		archive:   store,
+		rules:     rules,
+		queue:     newCallQueue(MaxQueueSize),
+		done:      make(chan struct{}),
+		shortName: shortName,
+		skip:      make(chan struct{}, 1),
+		subs:      make(map[chan api.NowPlaying]struct{}),
+	}
+	p.processedCalls.Store(processedCalls)
+	p.baselineOnly.Store(baselineOnly)
+	return p
+}
+
+// Run starts the fetcher and player goroutines and returns immediately;
+// they keep running until Stop is called.
+func (p *pipeline) Run() {
+	go p.fetchCalls()
+	go p.playAudio()
+}
+
+// Stop signals both goroutines to exit.
+func (p *pipeline) Stop() {
+	close(p.done)
+}
+
+func (p *pipeline) currentSystem() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.shortName
+}
+
+// currentRules returns the active talkgroup rules, or a permissive default
+// if no rules watcher was configured.
+func (p *pipeline) currentRules() *talkgroup.Config {
+	if p.rules == nil {
+		return &talkgroup.Config{}
+	}
+	return p.rules.Current()
+}
+
+func (p *pipeline) fetchCalls() {
+	for {
+		select {
+		case <-p.done:
+			p.logger.Info("Stopping call fetcher.")
+			return
+		case <-time.After(FetchInterval):
+			shortName := p.currentSystem()
+			apiURL := fmt.Sprintf("https://api.openmhz.com/%s/calls", shortName)
+
+			p.logger.Debug("Fetching calls...")
+			body, err := p.fetcher.Get(context.Background(), apiURL)
+			if err != nil {
+				p.logger.Error("Error fetching calls: ", err)
+				continue
+			}
+
+			var callsResponse CallsResponse
+			if err := json.Unmarshal(body, &callsResponse); err != nil {
+				p.logger.Error("Error parsing calls JSON: ", err)
+				continue
+			}
+
+			p.logger.Debugf("Parsed %d calls", len(callsResponse.Calls))
+
+			rules := p.currentRules()
+			processedCalls := p.processedCalls.Load()
+			baseline := p.baselineOnly.Load()
+			for _, call := range callsResponse.Calls {
+				if _, exists := processedCalls.LoadOrStore(call.ID, true); exists {
+					continue
+				}
+				if baseline {
+					// Nothing archived to dedupe against yet: record every
+					// call currently on the feed as already-seen instead of
+					// queuing it, so a fresh install doesn't play the whole
+					// backlog on its first poll.
+					continue
+				}
+
+				decision := rules.Evaluate(call.TalkgroupNum, call.TalkgroupTag, call.UnitIDs())
+				if !decision.Allow {
+					p.logger.Debugf("Dropping call %s: denied by talkgroup rules", call.ID)
+					continue
+				}
+
+				if !p.queue.Push(call, decision.Priority, decision.Gain) {
+					p.logger.Warnf("Queue full, dropping lower-priority call: %s", call.ID)
+					continue
+				}
+				p.logger.Infof("New call added to queue: %s (priority %d)", call.ID, decision.Priority)
+
+				if rules.Preempt && p.isPlaying.Load() && decision.Priority > int(p.currentPriority.Load()) {
+					p.logger.Infof("Preempting current playback for higher-priority call: %s", call.ID)
+					p.Skip()
+				}
+			}
+			if baseline {
+				p.baselineOnly.Store(false)
+			}
+		}
+	}
+}
+
+func (p *pipeline) playAudio() {
+	for {
+		if p.paused.Load() {
+			select {
+			case <-p.done:
+				p.logger.Info("Stopping audio player.")
+				return
+			case <-time.After(200 * time.Millisecond):
+				continue
+			}
+		}
+
+		call, priority, gain, ok := p.queue.Pop()
+		if !ok {
+			select {
+			case <-p.done:
+				p.logger.Info("Stopping audio player.")
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+
+		p.logger.Infof("Processing call: %s", call.Filename)
+
+		filePath := fmt.Sprintf("%s/%s", TempDownloadPath, filepath.Base(call.Filename))
+		if err := downloadFile(call.URL, filePath); err != nil {
+			p.logger.Error("Failed to download file: ", err)
+			continue
+		}
+
+		p.broadcastNowPlaying(call, priority)
+
+		p.currentPriority.Store(int32(priority))
+		p.isPlaying.Store(true)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-p.skip:
+				p.player.Stop(call.ID)
+			case <-ctx.Done():
+			}
+		}()
+
+		trackLength, err := p.player.Play(ctx, audio.Source{ID: call.ID, Path: filePath, Gain: gain})
+		cancel()
+		p.isPlaying.Store(false)
+		if err != nil {
+			p.logger.Error("Failed to play call: ", err)
+		} else {
+			p.logger.Infof("Track length: %s", trackLength)
+		}
+
+		p.archiveCall(call, filePath, trackLength)
+	}
+}
+
+// archiveCall moves a played call's file into the archive's system/date
+// layout and indexes it, instead of deleting it. If no archive is
+// configured, it falls back to the previous delete-after-play behavior.
+func (p *pipeline) archiveCall(call Call, filePath string, duration time.Duration) {
+	if p.archive == nil {
+		if err := os.Remove(filePath); err != nil {
+			p.logger.Warn("Failed to delete original file: ", err)
+		}
+		return
+	}
+
+	archivedAt := time.Now()
+	system := p.currentSystem()
+	archivedPath := archive.Path(AudioFolderPath, system, archivedAt, call.ID, filepath.Ext(filePath))
+
+	if err := os.MkdirAll(filepath.Dir(archivedPath), 0o755); err != nil {
+		p.logger.Warn("Failed to create archive directory: ", err)
+		return
+	}
+	if err := os.Rename(filePath, archivedPath); err != nil {
+		p.logger.Warn("Failed to move file into archive: ", err)
+		return
+	}
+
+	err := p.archive.Record(archive.Call{
+		ID:         call.ID,
+		System:     system,
+		URL:        call.URL,
+		Time:       call.Time,
+		Duration:   duration,
+		Talkgroup:  call.Talkgroup,
+		LocalPath:  archivedPath,
+		ArchivedAt: archivedAt,
+	})
+	if err != nil {
+		p.logger.Warn("Failed to index archived call: ", err)
+	}
+}
+
+func (p *pipeline) broadcastNowPlaying(call Call, priority int) {
+	startedAt := time.Now()
+
+	if p.mount != nil {
+		p.mount.SetNowPlaying(stream.NowPlaying{
+			CallID:     call.ID,
+			SystemName: p.currentSystem(),
+			StartedAt:  startedAt,
+		})
+	}
+
+	np := api.NowPlaying{
+		Call: api.QueuedCall{
+			ID:       call.ID,
+			Filename: call.Filename,
+			URL:      call.URL,
+			Time:     call.Time,
+			Priority: priority,
+		},
+		StartedAt: startedAt,
+	}
+
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	for ch := range p.subs {
+		select {
+		case ch <- np:
+		default:
+		}
+	}
+}
+
+// Systems implements api.Controller.
+func (p *pipeline) Systems() ([]api.SystemInfo, error) {
+	body, err := p.fetcher.Get(context.Background(), "https://api.openmhz.com/systems")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch systems: %w", err)
+	}
+
+	var systemsResponse SystemsResponse
+	if err := json.Unmarshal(body, &systemsResponse); err != nil {
+		return nil, fmt.Errorf("error parsing systems JSON: %w", err)
+	}
+
+	systems := make([]api.SystemInfo, 0, len(systemsResponse.Systems))
+	for _, s := range systemsResponse.Systems {
+		systems = append(systems, api.SystemInfo{
+			Name:      s.Name,
+			ShortName: s.ShortName,
+			City:      s.City,
+			State:     s.State,
+			Active:    s.Active,
+		})
+	}
+	return systems, nil
+}
+
+// SwitchSystem implements api.Controller.
+func (p *pipeline) SwitchSystem(shortName string) error {
+	if shortName == "" {
+		return fmt.Errorf("shortName is required")
+	}
+
+	p.mu.Lock()
+	p.shortName = shortName
+	p.mu.Unlock()
+
+	p.processedCalls.Store(&sync.Map{})
+	p.baselineOnly.Store(true)
+	p.logger.Infof("Switched to system %s", shortName)
+	return nil
+}
+
+// Queue implements api.Controller.
+func (p *pipeline) Queue() []api.QueuedCall {
+	items := p.queue.Snapshot()
+	snapshot := make([]api.QueuedCall, 0, len(items))
+	for _, item := range items {
+		snapshot = append(snapshot, api.QueuedCall{
+			ID:       item.call.ID,
+			Filename: item.call.Filename,
+			URL:      item.call.URL,
+			Time:     item.call.Time,
+			Priority: item.priority,
+		})
+	}
+	return snapshot
+}
+
+// RemoveFromQueue implements api.Controller.
+func (p *pipeline) RemoveFromQueue(id string) bool {
+	return p.queue.Remove(id)
+}
+
+// Skip implements api.Controller.
+func (p *pipeline) Skip() {
+	select {
+	case p.skip <- struct{}{}:
+	default:
+	}
+}
+
+// Pause implements api.Controller.
+func (p *pipeline) Pause(paused bool) {
+	p.paused.Store(paused)
+}
+
+// Metrics implements api.Controller.
+func (p *pipeline) Metrics() api.Metrics {
+	processed := 0
+	p.processedCalls.Load().Range(func(_, _ interface{}) bool {
+		processed++
+		return true
+	})
+	return api.Metrics{
+		QueueDepth:     p.queue.Len(),
+		ProcessedCalls: processed,
+		CurrentSystem:  p.currentSystem(),
+		Paused:         p.paused.Load(),
+	}
+}
+
+// Subscribe implements api.Controller.
+func (p *pipeline) Subscribe() (<-chan api.NowPlaying, func()) {
+	ch := make(chan api.NowPlaying, 4)
+
+	p.subsMu.Lock()
+	p.subs[ch] = struct{}{}
+	p.subsMu.Unlock()
+
+	cancel := func() {
+		p.subsMu.Lock()
+		delete(p.subs, ch)
+		p.subsMu.Unlock()
+		close(ch)
+	}
+	return ch, cancel
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// prioritizedCall is a single call waiting to play, ranked by the priority
+// assigned by talkgroup rules and then by arrival order so equal-priority
+// calls stay in FIFO order.
+type prioritizedCall struct {
+	call     Call
+	priority int
+	gain     float64
+	seq      int
+}
+
+// callHeap implements container/heap.Interface as a max-heap on priority.
+type callHeap []*prioritizedCall
+
+func (h callHeap) Len() int { return len(h) }
+func (h callHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h callHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *callHeap) Push(x interface{}) {
+	*h = append(*h, x.(*prioritizedCall))
+}
+func (h *callHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// callQueue is a bounded priority queue of pending calls. It replaces the
+// plain chan Call FIFO so talkgroup rules can reorder playback instead of
+// always dropping whatever arrived oldest.
+type callQueue struct {
+	mu      sync.Mutex
+	heap    callHeap
+	maxSize int
+	seq     int
+}
+
+func newCallQueue(maxSize int) *callQueue {
+	return &callQueue{maxSize: maxSize}
+}
+
+// Push adds call at the given priority/gain. If the queue is already full,
+// it evicts the lowest-priority item to make room for a higher-priority
+// newcomer; if call's own priority doesn't outrank the lowest queued item,
+// it is dropped instead and Push returns false.
+func (q *callQueue) Push(call Call, priority int, gain float64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	item := &prioritizedCall{call: call, priority: priority, gain: gain, seq: q.seq}
+
+	if len(q.heap) < q.maxSize {
+		heap.Push(&q.heap, item)
+		return true
+	}
+
+	lowest := q.lowestLocked()
+	if lowest == nil || item.priority <= lowest.priority {
+		return false
+	}
+	q.removeLocked(lowest)
+	heap.Push(&q.heap, item)
+	return true
+}
+
+func (q *callQueue) lowestLocked() *prioritizedCall {
+	var lowest *prioritizedCall
+	for _, item := range q.heap {
+		if lowest == nil || item.priority < lowest.priority ||
+			(item.priority == lowest.priority && item.seq > lowest.seq) {
+			lowest = item
+		}
+	}
+	return lowest
+}
+
+func (q *callQueue) removeLocked(target *prioritizedCall) {
+	for i, item := range q.heap {
+		if item == target {
+			heap.Remove(&q.heap, i)
+			return
+		}
+	}
+}
+
+// Pop removes and returns the highest-priority call, if any.
+func (q *callQueue) Pop() (call Call, priority int, gain float64, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.heap) == 0 {
+		return Call{}, 0, 0, false
+	}
+	item := heap.Pop(&q.heap).(*prioritizedCall)
+	return item.call, item.priority, item.gain, true
+}
+
+// Len reports how many calls are currently queued.
+func (q *callQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.heap)
+}
+
+// Snapshot returns the queued calls, highest priority first, without
+// removing them, for introspection (e.g. the REST API's /queue endpoint).
+func (q *callQueue) Snapshot() []prioritizedCall {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]prioritizedCall, len(q.heap))
+	for i, item := range q.heap {
+		items[i] = *item
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].priority != items[j].priority {
+			return items[i].priority > items[j].priority
+		}
+		return items[i].seq < items[j].seq
+	})
+	return items
+}
+
+// Remove deletes the queued call with the given ID, if present.
+func (q *callQueue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.heap {
+		if item.call.ID == id {
+			heap.Remove(&q.heap, i)
+			return true
+		}
+	}
+	return false
+}
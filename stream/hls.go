@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentDuration is the target length of each HLS segment. OpenMHz calls
+// are short and bursty, so a short segment keeps browser join latency low.
+const segmentDuration = 6 * time.Second
+
+// hlsSegment is one chunk of raw MP3 frames plus the sequence number it's
+// addressed by in the playlist. These are served as RFC 8216 packed-audio
+// segments (plain MP3, no MPEG-2 TS container) rather than real .ts files,
+// since the mount already emits MP3 and every major HLS client (Safari,
+// hls.js) accepts packed audio for audio-only variants.
+type hlsSegment struct {
+	seq  int
+	data []byte
+}
+
+// HLSServer republishes a Mount's MP3 frames as an HLS playlist + segment
+// set for browser clients that can't consume a raw Icecast mount.
+type HLSServer struct {
+	mount *Mount
+
+	mu       sync.Mutex
+	segments []hlsSegment
+	nextSeq  int
+}
+
+// NewHLSServer wraps mount with an HLS playlist/segment endpoint. Call Run
+// once to start segmenting.
+func NewHLSServer(mount *Mount) *HLSServer {
+	return &HLSServer{mount: mount}
+}
+
+// Run subscribes to the mount's listener fan-out and groups frames into
+// fixed-duration segments. It returns only if its listener channel is
+// closed, which the Mount never does, so in practice it runs for the life
+// of the process.
+func (h *HLSServer) Run() {
+	ch := make(chan []byte, 32)
+	h.mount.mu.Lock()
+	h.mount.listeners[ch] = struct{}{}
+	h.mount.mu.Unlock()
+	defer func() {
+		h.mount.mu.Lock()
+		delete(h.mount.listeners, ch)
+		h.mount.mu.Unlock()
+	}()
+
+	var buf []byte
+	ticker := time.NewTicker(segmentDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case frame, open := <-ch:
+			if !open {
+				return
+			}
+			buf = append(buf, frame...)
+		case <-ticker.C:
+			if len(buf) == 0 {
+				continue
+			}
+			h.pushSegment(buf)
+			buf = nil
+		}
+	}
+}
+
+func (h *HLSServer) pushSegment(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seg := hlsSegment{seq: h.nextSeq, data: data}
+	h.nextSeq++
+	h.segments = append(h.segments, seg)
+
+	// Keep a rolling window; live HLS players only need the last few.
+	const maxSegments = 6
+	if len(h.segments) > maxSegments {
+		h.segments = h.segments[len(h.segments)-maxSegments:]
+	}
+}
+
+// ServePlaylist serves the live .m3u8 playlist.
+func (h *HLSServer) ServePlaylist(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	segments := append([]hlsSegment(nil), h.segments...)
+	h.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(segmentDuration.Seconds()))
+	if len(segments) > 0 {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", segments[0].seq)
+	}
+	for _, seg := range segments {
+		fmt.Fprintf(&b, "#EXTINF:%.1f,\n", segmentDuration.Seconds())
+		fmt.Fprintf(&b, "segment-%d.mp3\n", seg.seq)
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(b.String()))
+}
+
+// ServeSegment serves a single segment addressed as /hls/segment-<seq>.mp3.
+func (h *HLSServer) ServeSegment(w http.ResponseWriter, r *http.Request) {
+	var seq int
+	name := strings.TrimPrefix(r.URL.Path, "/hls/segment-")
+	name = strings.TrimSuffix(name, ".mp3")
+	if _, err := fmt.Sscanf(name, "%d", &seq); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, seg := range h.segments {
+		if seg.seq == seq {
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write(seg.data)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
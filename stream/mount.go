@@ -0,0 +1,232 @@
+// Package stream re-encodes the PCM tapped from the audio player into a
+// continuous Icecast-style MP3 mount (and an HLS playlist for browser
+// clients), so multiple listeners can tune in over HTTP instead of only
+// hearing audio played locally on the Pi.
+package stream
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/viert/lame"
+)
+
+const (
+	// ringBufferFrames bounds how much recently-encoded audio is kept
+	// around for listeners that join mid-stream.
+	ringBufferFrames = 256
+	// icyMetaInterval is the byte interval ICY clients expect metadata
+	// blocks at, matching the de-facto Shoutcast/Icecast convention.
+	icyMetaInterval = 16000
+	// spacerSilence is inserted between calls so back-to-back
+	// transmissions don't run together in the re-stream.
+	spacerSilence = 300 * time.Millisecond
+)
+
+// NowPlaying identifies the call currently feeding the mount, used to build
+// the ICY StreamTitle shown by players like VLC.
+type NowPlaying struct {
+	CallID     string
+	SystemName string
+	StartedAt  time.Time
+}
+
+func (n NowPlaying) title() string {
+	return fmt.Sprintf("%s - %s - %s", n.SystemName, n.CallID, n.StartedAt.Format("15:04:05"))
+}
+
+// PCMSource is anything that can be tapped for live PCM, implemented by
+// audio.Player.
+type PCMSource interface {
+	Tap() (<-chan []byte, func())
+}
+
+// Mount is a single Icecast-compatible re-stream endpoint. One Mount
+// serves every connected listener from a single encoder via a fan-out
+// writer, rather than re-encoding per client.
+type Mount struct {
+	logger *logrus.Logger
+	source PCMSource
+
+	mu        sync.Mutex
+	ring      [][]byte
+	listeners map[chan []byte]struct{}
+	current   NowPlaying
+}
+
+// NewMount builds a Mount that will encode PCM tapped from source.
+func NewMount(logger *logrus.Logger, source PCMSource) *Mount {
+	return &Mount{
+		logger:    logger,
+		source:    source,
+		listeners: make(map[chan []byte]struct{}),
+	}
+}
+
+// SetNowPlaying updates the metadata embedded in the ICY StreamTitle for
+// subsequent MP3 frames. Callers (the pipeline) call this whenever a new
+// call starts playing.
+func (m *Mount) SetNowPlaying(np NowPlaying) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current = np
+}
+
+// Run encodes tapped PCM to MP3 and fans it out to listeners until the
+// source's tap channel closes. It should be started once, in its own
+// goroutine.
+func (m *Mount) Run() {
+	pcm, cancel := m.source.Tap()
+	defer cancel()
+
+	encoder := lame.Init()
+	encoder.SetInSamplerate(44100)
+	encoder.SetNumChannels(2)
+	encoder.SetBitrate(128)
+	encoder.SetQuality(5)
+	if ret := encoder.InitParams(); ret < 0 {
+		m.logger.Errorf("Failed to initialize MP3 encoder (code %d), re-stream disabled", ret)
+		return
+	}
+	defer encoder.Close()
+
+	var lastFrame time.Time
+	for chunk := range pcm {
+		if !lastFrame.IsZero() && time.Since(lastFrame) > time.Second {
+			m.writeSilence(encoder)
+		}
+		lastFrame = time.Now()
+
+		m.publish(encoder.Encode(chunk))
+	}
+}
+
+func (m *Mount) writeSilence(encoder *lame.Encoder) {
+	silenceSamples := int(float64(44100) * spacerSilence.Seconds())
+	silence := make([]byte, silenceSamples*2*2) // 16-bit stereo
+	m.publish(encoder.Encode(silence))
+}
+
+func (m *Mount) publish(mp3Bytes []byte) {
+	if len(mp3Bytes) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	m.ring = append(m.ring, mp3Bytes)
+	if len(m.ring) > ringBufferFrames {
+		m.ring = m.ring[len(m.ring)-ringBufferFrames:]
+	}
+	for ch := range m.listeners {
+		select {
+		case ch <- mp3Bytes:
+		default:
+			m.logger.Debug("Re-stream listener backpressured, dropping frame")
+		}
+	}
+	m.mu.Unlock()
+}
+
+// ServeHTTP implements the Icecast-compatible /stream.mp3 mount: it sends
+// recent ring-buffer frames to catch the new listener up, then streams live
+// frames indefinitely, periodically injecting ICY StreamTitle metadata.
+func (m *Mount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wantsICY := r.Header.Get("Icy-MetaData") == "1"
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("icy-name", "OpenMHzPi")
+	if wantsICY {
+		w.Header().Set("icy-metaint", fmt.Sprintf("%d", icyMetaInterval))
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan []byte, 32)
+	m.mu.Lock()
+	backlog := m.ring
+	if len(backlog) > cap(ch) {
+		backlog = backlog[len(backlog)-cap(ch):]
+	}
+	for _, frame := range backlog {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+	m.listeners[ch] = struct{}{}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.listeners, ch)
+		m.mu.Unlock()
+	}()
+
+	bytesSinceMeta := 0
+	for {
+		select {
+		case frame, open := <-ch:
+			if !open {
+				return
+			}
+			if err := m.writeFrame(w, frame, wantsICY, &bytesSinceMeta); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (m *Mount) writeFrame(w io.Writer, frame []byte, wantsICY bool, bytesSinceMeta *int) error {
+	if !wantsICY {
+		_, err := w.Write(frame)
+		return err
+	}
+
+	for len(frame) > 0 {
+		remaining := icyMetaInterval - *bytesSinceMeta
+		chunk := frame
+		if len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		*bytesSinceMeta += len(chunk)
+		frame = frame[len(chunk):]
+
+		if *bytesSinceMeta >= icyMetaInterval {
+			if err := m.writeICYMeta(w); err != nil {
+				return err
+			}
+			*bytesSinceMeta = 0
+		}
+	}
+	return nil
+}
+
+func (m *Mount) writeICYMeta(w io.Writer) error {
+	m.mu.Lock()
+	title := m.current.title()
+	m.mu.Unlock()
+
+	meta := fmt.Sprintf("StreamTitle='%s';", title)
+	padded := len(meta)
+	blocks := (padded + 15) / 16
+	buf := make([]byte, 1+blocks*16)
+	buf[0] = byte(blocks)
+	copy(buf[1:], meta)
+
+	_, err := w.Write(buf)
+	return err
+}
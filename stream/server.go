@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Server binds the Icecast-style mount and HLS endpoints to a single HTTP
+// listener, separate from the control API so a scanner client (VLC,
+// hls.js) can point at one predictable address.
+type Server struct {
+	logger *logrus.Logger
+	addr   string
+	mount  *Mount
+	hls    *HLSServer
+}
+
+// NewServer builds a Server serving mount at /stream.mp3 and an HLS
+// playlist/segment set under /hls/.
+func NewServer(logger *logrus.Logger, addr string, mount *Mount) *Server {
+	return &Server{
+		logger: logger,
+		addr:   addr,
+		mount:  mount,
+		hls:    NewHLSServer(mount),
+	}
+}
+
+// ListenAndServe starts the mount/HLS encoder goroutines and blocks serving
+// HTTP until it returns an error.
+func (s *Server) ListenAndServe() error {
+	go s.mount.Run()
+	go s.hls.Run()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream.mp3", s.mount.ServeHTTP)
+	mux.HandleFunc("/hls/stream.m3u8", s.hls.ServePlaylist)
+	mux.HandleFunc("/hls/", s.hls.ServeSegment)
+
+	s.logger.Infof("Re-stream mount listening on %s", s.addr)
+	return http.ListenAndServe(s.addr, mux)
+}
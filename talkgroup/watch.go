@@ -0,0 +1,98 @@
+package talkgroup
+
+import (
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// Watcher holds the current Config and reloads it from disk whenever the
+// underlying file changes, so operators can tweak filters without
+// restarting the process.
+type Watcher struct {
+	logger  *logrus.Logger
+	path    string
+	current atomic.Pointer[Config]
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// NewWatcher loads path (if non-empty) and, once loaded, watches it for
+// changes in the background. An empty path returns a Watcher that always
+// reports the permissive default Config and does no filesystem watching.
+func NewWatcher(logger *logrus.Logger, path string) (*Watcher, error) {
+	w := &Watcher{logger: logger, path: path, done: make(chan struct{})}
+
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	w.current.Store(cfg)
+
+	if path == "" {
+		return w, nil
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the containing directory rather than the file itself: editors
+	// commonly replace a file via rename-on-save, which would silently stop
+	// a watch placed directly on the old inode.
+	if err := fsw.Add(filepath.Dir(path)); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	w.watcher = fsw
+
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(w.path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			cfg, err := Load(w.path)
+			if err != nil {
+				w.logger.Warn("Failed to reload talkgroup rules, keeping previous rules: ", err)
+				continue
+			}
+			w.current.Store(cfg)
+			w.logger.Info("Reloaded talkgroup rules from ", w.path)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("Talkgroup rules watcher error: ", err)
+		}
+	}
+}
+
+// Current returns the most recently loaded Config. Safe for concurrent use.
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Close stops the background watch goroutine.
+func (w *Watcher) Close() error {
+	close(w.done)
+	if w.watcher != nil {
+		return w.watcher.Close()
+	}
+	return nil
+}